@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+const computeMod int64 = 1000003
+
+// computeSemaphore caps the total number of concurrent compute worker
+// goroutines across all in-flight /api/v1/auth/load-test requests, so a
+// burst of parallel requests can't oversubscribe the machine's cores.
+var computeSemaphore chan struct{}
+
+// initComputeSemaphore sizes computeSemaphore from COMPUTE_MAX_CONCURRENT_WORKERS.
+// It must run after loadEnv() (called from main()): a package-level var
+// initializer runs before main() executes, i.e. before loadEnv() has had a
+// chance to load .env, which would silently ignore a value set there.
+func initComputeSemaphore() {
+	computeSemaphore = make(chan struct{}, getEnvInt("COMPUTE_MAX_CONCURRENT_WORKERS", runtime.GOMAXPROCS(0)*4))
+}
+
+// compute partitions the iterations×items workload across parallelism
+// worker goroutines (fan-out), each summing a partial accumulator over its
+// slice of iterations against the shared read-only items slice, then sums
+// the partials mod computeMod (fan-in). It honors ctx cancellation so a
+// disconnected client doesn't keep workers pinning cores.
+func compute(ctx context.Context, items []int, iterations, multiplier, parallelism int) (int64, error) {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	if parallelism > iterations {
+		parallelism = iterations
+	}
+	if parallelism <= 1 {
+		return computeRange(ctx, items, multiplier, 0, iterations)
+	}
+
+	chunk := (iterations + parallelism - 1) / parallelism
+	partials := make([]int64, parallelism)
+	errs := make([]error, parallelism)
+
+	var wg sync.WaitGroup
+	for w := 0; w < parallelism; w++ {
+		start := w * chunk
+		end := start + chunk
+		if end > iterations {
+			end = iterations
+		}
+		if start >= end {
+			continue
+		}
+
+		select {
+		case computeSemaphore <- struct{}{}:
+		case <-ctx.Done():
+			errs[w] = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(w, start, end int) {
+			defer wg.Done()
+			defer func() { <-computeSemaphore }()
+			partials[w], errs[w] = computeRange(ctx, items, multiplier, start, end)
+		}(w, start, end)
+	}
+	wg.Wait()
+
+	var acc int64
+	for _, err := range errs {
+		if err != nil {
+			return 0, err
+		}
+	}
+	for _, partial := range partials {
+		acc = (acc + partial) % computeMod
+	}
+	return acc, nil
+}
+
+// computeRange sums the compute kernel over iterations [start, end) against
+// items, checking ctx periodically so long-running workers notice
+// cancellation without paying the overhead of checking on every iteration.
+func computeRange(ctx context.Context, items []int, multiplier, start, end int) (int64, error) {
+	var acc int64
+	for i := start; i < end; i++ {
+		if i%1024 == 0 {
+			select {
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			default:
+			}
+		}
+		for _, v := range items {
+			x := int64(v*multiplier + i + 1)
+			x = (x*x + 31) % computeMod
+			x = (x*x + 17) % computeMod
+			acc = (acc + x) % computeMod
+		}
+	}
+	return acc, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	dataAllGroup singleflight.Group
+	dataAllCache *ttlCache
+)
+
+// initDataCache reads DB_DATA_CACHE_TTL and builds dataAllCache. It must run
+// after loadEnv() (called from main()) so a TTL set via .env is honored, not
+// just one exported as a real shell env var: package-level var initializers
+// run before main(), i.e. before loadEnv() has had a chance to load .env.
+func initDataCache() {
+	dataAllCache = newTTLCache(loadDataAllCacheTTL())
+}
+
+// ttlCache holds cached *DBPayload entries, one per distinct dataFilter, for
+// up to ttl. ttl <= 0 disables caching entirely (every read is a miss).
+type ttlCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]ttlCacheEntry
+}
+
+type ttlCacheEntry struct {
+	payload   *DBPayload
+	expiresAt time.Time
+}
+
+func newTTLCache(ttl time.Duration) *ttlCache {
+	return &ttlCache{ttl: ttl, entries: make(map[string]ttlCacheEntry)}
+}
+
+func (c *ttlCache) get(key string) (*DBPayload, bool) {
+	if c.ttl <= 0 {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.payload, true
+}
+
+func (c *ttlCache) set(key string, payload *DBPayload) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = ttlCacheEntry{payload: payload, expiresAt: time.Now().Add(c.ttl)}
+}
+
+// loadDataAllCacheTTL reads DB_DATA_CACHE_TTL (a Go duration string, e.g.
+// "2s"). An empty or invalid value disables the cache, matching the
+// existing "unset env var disables the feature" convention used for DB_DSN.
+func loadDataAllCacheTTL() time.Duration {
+	raw := os.Getenv("DB_DATA_CACHE_TTL")
+	if raw == "" {
+		return 0
+	}
+	ttl, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Printf("invalid DB_DATA_CACHE_TTL %q, caching disabled: %v", raw, err)
+		return 0
+	}
+	return ttl
+}
+
+// getDBPayload serves /api/v1/data/all's dataset for a given filter: a
+// short-lived cache hit avoids hitting MySQL at all, and a miss is
+// deduplicated across concurrent callers of the same filter via singleflight
+// so a load-test burst still issues one DB round-trip instead of one per
+// request.
+//
+// ctx is intentionally NOT passed into the singleflight closure below:
+// singleflight only invokes the closure for the first ("leader") caller of
+// a given key, and every other concurrent caller just waits on its result.
+// If the leader's own request context were used and its client disconnected
+// mid-fetch, that cancellation would fail every other still-live caller
+// sharing the key too. The shared fetch instead runs on its own
+// background context with a fixed timeout, independent of any one caller.
+func getDBPayload(f *dataFilter) (*DBPayload, error) {
+	key := f.cacheKey()
+
+	if payload, ok := dataAllCache.get(key); ok {
+		return payload, nil
+	}
+
+	v, err, _ := dataAllGroup.Do(key, func() (interface{}, error) {
+		fetchCtx, cancel := context.WithTimeout(context.Background(), getEnvDuration("DB_DATA_FETCH_TIMEOUT", 10*time.Second))
+		defer cancel()
+
+		payload, err := loadDBPayload(fetchCtx, f)
+		if err != nil {
+			return nil, err
+		}
+		dataAllCache.set(key, payload)
+		return payload, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*DBPayload), nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// dataFilter captures the query parameters accepted by /api/v1/data/all: a
+// shared page size, an independent keyset cursor per table (their id
+// columns are unrelated auto-increment spaces, so one shared cursor can't
+// page through all four correctly), a handful of per-table filters, and the
+// response format.
+type dataFilter struct {
+	Limit  int
+	Format string
+
+	UsersCursor            uint64
+	ProductsCursor         uint64
+	TransactionsCursor     uint64
+	TransactionItemsCursor uint64
+
+	Status      string
+	IsActive    *bool
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+}
+
+// parseDataFilter reads and validates the request's query parameters. Every
+// parameter is optional; omitting all of them reproduces a bounded first
+// page of the default size.
+func parseDataFilter(r *http.Request) (*dataFilter, error) {
+	q := r.URL.Query()
+	f := &dataFilter{Limit: defaultPageLimit, Format: formatJSON}
+
+	if raw := q.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("limit must be a positive integer")
+		}
+		if limit > maxPageLimit {
+			limit = maxPageLimit
+		}
+		f.Limit = limit
+	}
+
+	cursors := []struct {
+		param string
+		dst   *uint64
+	}{
+		{"users_cursor", &f.UsersCursor},
+		{"products_cursor", &f.ProductsCursor},
+		{"transactions_cursor", &f.TransactionsCursor},
+		{"transaction_items_cursor", &f.TransactionItemsCursor},
+	}
+	for _, c := range cursors {
+		raw := q.Get(c.param)
+		if raw == "" {
+			continue
+		}
+		cursor, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a non-negative integer", c.param)
+		}
+		*c.dst = cursor
+	}
+
+	if raw := q.Get("format"); raw != "" {
+		switch raw {
+		case formatJSON, formatNDJSON:
+			f.Format = raw
+		default:
+			return nil, fmt.Errorf("format must be %q or %q", formatJSON, formatNDJSON)
+		}
+	}
+
+	f.Status = q.Get("status")
+
+	if raw := q.Get("is_active"); raw != "" {
+		isActive, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("is_active must be a boolean")
+		}
+		f.IsActive = &isActive
+	}
+
+	if raw := q.Get("created_from"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("created_from must be an RFC3339 timestamp")
+		}
+		f.CreatedFrom = &t
+	}
+
+	if raw := q.Get("created_to"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("created_to must be an RFC3339 timestamp")
+		}
+		f.CreatedTo = &t
+	}
+
+	return f, nil
+}
+
+// cacheKey returns a stable string identifying this filter for use as a
+// cache/singleflight key. Format is deliberately excluded by callers:
+// ndjson requests stream and never consult the cache.
+func (f *dataFilter) cacheKey() string {
+	key := fmt.Sprintf("limit=%d&users_cursor=%d&products_cursor=%d&transactions_cursor=%d&transaction_items_cursor=%d&status=%s",
+		f.Limit, f.UsersCursor, f.ProductsCursor, f.TransactionsCursor, f.TransactionItemsCursor, f.Status)
+	if f.IsActive != nil {
+		key += fmt.Sprintf("&is_active=%t", *f.IsActive)
+	}
+	if f.CreatedFrom != nil {
+		key += "&created_from=" + f.CreatedFrom.Format(time.RFC3339)
+	}
+	if f.CreatedTo != nil {
+		key += "&created_to=" + f.CreatedTo.Format(time.RFC3339)
+	}
+	return key
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// sqlFilterBuilder accumulates WHERE clauses and their positional args for a
+// single keyset-paginated, filtered query.
+type sqlFilterBuilder struct {
+	clauses []string
+	args    []interface{}
+}
+
+func (b *sqlFilterBuilder) where(clause string, arg interface{}) {
+	b.clauses = append(b.clauses, clause)
+	b.args = append(b.args, arg)
+}
+
+// build appends the accumulated WHERE clauses (if any) plus a stable
+// ORDER BY id ASC LIMIT ? to baseQuery, returning it with its full arg list.
+func (b *sqlFilterBuilder) build(baseQuery string, limit int) (string, []interface{}) {
+	query := baseQuery
+	if len(b.clauses) > 0 {
+		query += " WHERE " + strings.Join(b.clauses, " AND ")
+	}
+	query += " ORDER BY id ASC LIMIT ?"
+	args := append(append([]interface{}{}, b.args...), limit)
+	return query, args
+}
+
+func buildUsersQuery(f *dataFilter) (string, []interface{}) {
+	b := &sqlFilterBuilder{}
+	if f.UsersCursor > 0 {
+		b.where("id > ?", f.UsersCursor)
+	}
+	if f.Status != "" {
+		b.where("status = ?", f.Status)
+	}
+	if f.CreatedFrom != nil {
+		b.where("created_at >= ?", *f.CreatedFrom)
+	}
+	if f.CreatedTo != nil {
+		b.where("created_at <= ?", *f.CreatedTo)
+	}
+	return b.build(`SELECT id, email, full_name, phone, password_hash, status, created_at, updated_at FROM users`, f.Limit)
+}
+
+func buildProductsQuery(f *dataFilter) (string, []interface{}) {
+	b := &sqlFilterBuilder{}
+	if f.ProductsCursor > 0 {
+		b.where("id > ?", f.ProductsCursor)
+	}
+	if f.IsActive != nil {
+		b.where("is_active = ?", *f.IsActive)
+	}
+	if f.CreatedFrom != nil {
+		b.where("created_at >= ?", *f.CreatedFrom)
+	}
+	if f.CreatedTo != nil {
+		b.where("created_at <= ?", *f.CreatedTo)
+	}
+	return b.build(`SELECT id, sku, name, description, price_cents, stock, is_active, created_at, updated_at FROM products`, f.Limit)
+}
+
+func buildTransactionsQuery(f *dataFilter) (string, []interface{}) {
+	b := &sqlFilterBuilder{}
+	if f.TransactionsCursor > 0 {
+		b.where("id > ?", f.TransactionsCursor)
+	}
+	if f.Status != "" {
+		b.where("status = ?", f.Status)
+	}
+	if f.CreatedFrom != nil {
+		b.where("created_at >= ?", *f.CreatedFrom)
+	}
+	if f.CreatedTo != nil {
+		b.where("created_at <= ?", *f.CreatedTo)
+	}
+	return b.build(`SELECT id, user_id, order_no, status, currency, total_cents, item_count, payment_method, created_at, updated_at FROM transactions`, f.Limit)
+}
+
+func buildTransactionItemsQuery(f *dataFilter) (string, []interface{}) {
+	b := &sqlFilterBuilder{}
+	if f.TransactionItemsCursor > 0 {
+		b.where("id > ?", f.TransactionItemsCursor)
+	}
+	if f.CreatedFrom != nil {
+		b.where("created_at >= ?", *f.CreatedFrom)
+	}
+	if f.CreatedTo != nil {
+		b.where("created_at <= ?", *f.CreatedTo)
+	}
+	return b.build(`SELECT id, transaction_id, product_id, qty, unit_price_cents, line_total_cents, created_at FROM transaction_items`, f.Limit)
+}
+
+func scanUser(rows *sql.Rows) (User, error) {
+	var user User
+	var phone sql.NullString
+	if err := rows.Scan(
+		&user.ID,
+		&user.Email,
+		&user.FullName,
+		&phone,
+		&user.PasswordHash,
+		&user.Status,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	); err != nil {
+		return User{}, err
+	}
+	if phone.Valid {
+		val := phone.String
+		user.Phone = &val
+	}
+	return user, nil
+}
+
+func scanProduct(rows *sql.Rows) (Product, error) {
+	var prod Product
+	var description sql.NullString
+	if err := rows.Scan(
+		&prod.ID,
+		&prod.SKU,
+		&prod.Name,
+		&description,
+		&prod.PriceCents,
+		&prod.Stock,
+		&prod.IsActive,
+		&prod.CreatedAt,
+		&prod.UpdatedAt,
+	); err != nil {
+		return Product{}, err
+	}
+	if description.Valid {
+		val := description.String
+		prod.Description = &val
+	}
+	return prod, nil
+}
+
+func scanTransaction(rows *sql.Rows) (Transaction, error) {
+	var txn Transaction
+	if err := rows.Scan(
+		&txn.ID,
+		&txn.UserID,
+		&txn.OrderNo,
+		&txn.Status,
+		&txn.Currency,
+		&txn.TotalCents,
+		&txn.ItemCount,
+		&txn.PaymentMethod,
+		&txn.CreatedAt,
+		&txn.UpdatedAt,
+	); err != nil {
+		return Transaction{}, err
+	}
+	return txn, nil
+}
+
+func scanTransactionItem(rows *sql.Rows) (TransactionItem, error) {
+	var item TransactionItem
+	if err := rows.Scan(
+		&item.ID,
+		&item.TransactionID,
+		&item.ProductID,
+		&item.Qty,
+		&item.UnitPriceCents,
+		&item.LineTotalCents,
+		&item.CreatedAt,
+	); err != nil {
+		return TransactionItem{}, err
+	}
+	return item, nil
+}
+
+func fetchUsersPage(ctx context.Context, f *dataFilter) ([]User, error) {
+	query, args := buildUsersQuery(f)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func fetchProductsPage(ctx context.Context, f *dataFilter) ([]Product, error) {
+	query, args := buildProductsQuery(f)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []Product
+	for rows.Next() {
+		prod, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, prod)
+	}
+	return products, rows.Err()
+}
+
+func fetchTransactionsPage(ctx context.Context, f *dataFilter) ([]Transaction, error) {
+	query, args := buildTransactionsQuery(f)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var txns []Transaction
+	for rows.Next() {
+		txn, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		txns = append(txns, txn)
+	}
+	return txns, rows.Err()
+}
+
+func fetchTransactionItemsPage(ctx context.Context, f *dataFilter) ([]TransactionItem, error) {
+	query, args := buildTransactionItemsQuery(f)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []TransactionItem
+	for rows.Next() {
+		item, err := scanTransactionItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
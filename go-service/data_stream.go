@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+)
+
+// ndjsonFlushEvery controls how often streamDataNDJSON flushes the
+// underlying connection; flushing every row would be wasteful for a fast
+// client, and never flushing would defeat the point of streaming.
+const ndjsonFlushEvery = 50
+
+type ndjsonLine struct {
+	Table string      `json:"table"`
+	Data  interface{} `json:"data"`
+}
+
+// streamDataNDJSON writes one JSON object per line for every row across the
+// four tables (users, then products, then transactions, then transaction
+// items), flushing periodically so clients can consume results
+// incrementally instead of waiting for the whole dataset to buffer in
+// memory.
+func streamDataNDJSON(ctx context.Context, w http.ResponseWriter, f *dataFilter) error {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Transfer-Encoding", "chunked")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+
+	usersQuery, usersArgs := buildUsersQuery(f)
+	if err := streamRows(ctx, flusher, usersQuery, usersArgs, func(rows *sql.Rows) error {
+		user, err := scanUser(rows)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(ndjsonLine{Table: "users", Data: user})
+	}); err != nil {
+		return err
+	}
+
+	productsQuery, productsArgs := buildProductsQuery(f)
+	if err := streamRows(ctx, flusher, productsQuery, productsArgs, func(rows *sql.Rows) error {
+		prod, err := scanProduct(rows)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(ndjsonLine{Table: "products", Data: prod})
+	}); err != nil {
+		return err
+	}
+
+	transactionsQuery, transactionsArgs := buildTransactionsQuery(f)
+	if err := streamRows(ctx, flusher, transactionsQuery, transactionsArgs, func(rows *sql.Rows) error {
+		txn, err := scanTransaction(rows)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(ndjsonLine{Table: "transactions", Data: txn})
+	}); err != nil {
+		return err
+	}
+
+	itemsQuery, itemsArgs := buildTransactionItemsQuery(f)
+	return streamRows(ctx, flusher, itemsQuery, itemsArgs, func(rows *sql.Rows) error {
+		item, err := scanTransactionItem(rows)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(ndjsonLine{Table: "transaction_items", Data: item})
+	})
+}
+
+// streamRows runs query against db and calls encodeRow once per row,
+// flushing the response writer every ndjsonFlushEvery rows and once more
+// after the last row.
+func streamRows(ctx context.Context, flusher http.Flusher, query string, args []interface{}, encodeRow func(*sql.Rows) error) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		if err := encodeRow(rows); err != nil {
+			return err
+		}
+		n++
+		if flusher != nil && n%ndjsonFlushEvery == 0 {
+			flusher.Flush()
+		}
+	}
+	if flusher != nil {
+		flusher.Flush()
+	}
+	return rows.Err()
+}
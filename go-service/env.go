@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// getEnvInt reads key as an int, falling back to def if unset or invalid.
+func getEnvInt(key string, def int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		log.Printf("invalid %s=%q, using default %d: %v", key, raw, def, err)
+		return def
+	}
+	return v
+}
+
+// getEnvDuration reads key as a Go duration string (e.g. "5s", "1m"),
+// falling back to def if unset or invalid.
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	v, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Printf("invalid %s=%q, using default %s: %v", key, raw, def, err)
+		return def
+	}
+	return v
+}
+
+// getEnvFloat reads key as a float64, falling back to def if unset or
+// invalid.
+func getEnvFloat(key string, def float64) float64 {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		log.Printf("invalid %s=%q, using default %g: %v", key, raw, def, err)
+		return def
+	}
+	return v
+}
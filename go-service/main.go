@@ -7,30 +7,31 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"sync/atomic"
+	"os/signal"
+	"runtime"
+	"syscall"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/sync/errgroup"
 )
 
 type LoadTestRequest struct {
-	Items      []int `json:"items"`
-	Iterations int   `json:"iterations"`
-	Multiplier int   `json:"multiplier"`
+	Items       []int `json:"items"`
+	Iterations  int   `json:"iterations"`
+	Multiplier  int   `json:"multiplier"`
+	Parallelism int   `json:"parallelism,omitempty"`
 }
 
 type LoadTestResponse struct {
-	Ok         bool  `json:"ok"`
-	Result     int64 `json:"result"`
-	Count      int   `json:"count"`
-	Iterations int   `json:"iterations"`
-	Multiplier int   `json:"multiplier"`
-}
-
-type Metrics struct {
-	Requests uint64 `json:"requests"`
-	Errors   uint64 `json:"errors"`
+	Ok          bool  `json:"ok"`
+	Result      int64 `json:"result"`
+	Count       int   `json:"count"`
+	Iterations  int   `json:"iterations"`
+	Multiplier  int   `json:"multiplier"`
+	Parallelism int   `json:"parallelism"`
 }
 
 type User struct {
@@ -79,18 +80,27 @@ type TransactionItem struct {
 	CreatedAt      time.Time `json:"created_at"`
 }
 
+// DBPageInfo reports the keyset cursor a client should send back as the
+// matching *_cursor query param to fetch the next page of that table.
+// NextCursor is zero and HasMore is false once a table is exhausted.
+type DBPageInfo struct {
+	NextCursor uint64 `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
 type DBPayload struct {
 	Users            []User            `json:"users"`
 	Products         []Product         `json:"products"`
 	Transactions     []Transaction     `json:"transactions"`
 	TransactionItems []TransactionItem `json:"transaction_items"`
+
+	UsersPage            DBPageInfo `json:"users_page"`
+	ProductsPage         DBPageInfo `json:"products_page"`
+	TransactionsPage     DBPageInfo `json:"transactions_page"`
+	TransactionItemsPage DBPageInfo `json:"transaction_items_page"`
 }
 
-var (
-	reqCount atomic.Uint64
-	errCount atomic.Uint64
-	db       *sql.DB
-)
+var db *sql.DB
 
 func loadEnv() {
 	if err := godotenv.Load(); err != nil {
@@ -98,21 +108,6 @@ func loadEnv() {
 	}
 }
 
-func compute(items []int, iterations, multiplier int) int64 {
-	var acc int64 = 0
-	const mod int64 = 1000003
-
-	for i := 0; i < iterations; i++ {
-		for _, v := range items {
-			x := int64(v*multiplier + i + 1)
-			x = (x*x + 31) % mod
-			x = (x*x + 17) % mod
-			acc = (acc + x) % mod
-		}
-	}
-	return acc
-}
-
 func healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]any{
@@ -122,19 +117,8 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func metricsHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(Metrics{
-		Requests: reqCount.Load(),
-		Errors:   errCount.Load(),
-	})
-}
-
 func loadTestHandler(w http.ResponseWriter, r *http.Request) {
-	reqCount.Add(1)
-
 	if r.Method != http.MethodPost {
-		errCount.Add(1)
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
@@ -144,36 +128,46 @@ func loadTestHandler(w http.ResponseWriter, r *http.Request) {
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
 	if err := dec.Decode(&req); err != nil {
-		errCount.Add(1)
 		http.Error(w, "invalid json body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	if req.Iterations <= 0 || req.Iterations > 100000 {
-		errCount.Add(1)
 		http.Error(w, "iterations must be in range 1..100000", http.StatusBadRequest)
 		return
 	}
 	if req.Multiplier <= 0 || req.Multiplier > 100000 {
-		errCount.Add(1)
 		http.Error(w, "multiplier must be in range 1..100000", http.StatusBadRequest)
 		return
 	}
 	if len(req.Items) == 0 || len(req.Items) > 100000 {
-		errCount.Add(1)
 		http.Error(w, "items length must be in range 1..100000", http.StatusBadRequest)
 		return
 	}
+	if req.Parallelism == 0 {
+		req.Parallelism = runtime.GOMAXPROCS(0)
+	}
+	if req.Parallelism < 1 || req.Parallelism > 256 {
+		http.Error(w, "parallelism must be in range 1..256", http.StatusBadRequest)
+		return
+	}
 
-	result := compute(req.Items, req.Iterations, req.Multiplier)
+	computeStart := time.Now()
+	result, err := compute(r.Context(), req.Items, req.Iterations, req.Multiplier, req.Parallelism)
+	computeDurationSeconds.Observe(time.Since(computeStart).Seconds())
+	if err != nil {
+		http.Error(w, "compute cancelled: "+err.Error(), http.StatusRequestTimeout)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(LoadTestResponse{
-		Ok:         true,
-		Result:     result,
-		Count:      len(req.Items),
-		Iterations: req.Iterations,
-		Multiplier: req.Multiplier,
+		Ok:          true,
+		Result:      result,
+		Count:       len(req.Items),
+		Iterations:  req.Iterations,
+		Multiplier:  req.Multiplier,
+		Parallelism: req.Parallelism,
 	})
 }
 
@@ -188,182 +182,128 @@ func dbDataHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := r.Context()
-	users, err := fetchUsers(ctx)
+	filter, err := parseDataFilter(r)
 	if err != nil {
-		http.Error(w, "failed to load users: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	products, err := fetchProducts(ctx)
-	if err != nil {
-		http.Error(w, "failed to load products: "+err.Error(), http.StatusInternalServerError)
+
+	if filter.Format == formatNDJSON {
+		if err := streamDataNDJSON(r.Context(), w, filter); err != nil {
+			log.Printf("ndjson stream for /api/v1/data/all failed: %v", err)
+		}
 		return
 	}
-	transactions, err := fetchTransactions(ctx)
+
+	payload, err := getDBPayload(filter)
 	if err != nil {
-		http.Error(w, "failed to load transactions: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "failed to load data: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	transactionItems, err := fetchTransactionItems(ctx)
-	if err != nil {
-		http.Error(w, "failed to load transaction items: "+err.Error(), http.StatusInternalServerError)
-		return
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+// loadDBPayload runs the four table fetches concurrently via
+// errgroup.WithContext: the first error cancels ctx, which in turn cancels
+// the sibling queries' QueryContext calls instead of letting them run to
+// completion uselessly.
+func loadDBPayload(ctx context.Context, f *dataFilter) (*DBPayload, error) {
+	var (
+		users            []User
+		products         []Product
+		transactions     []Transaction
+		transactionItems []TransactionItem
+	)
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() (err error) {
+		users, err = fetchUsersPage(gctx, f)
+		return err
+	})
+	g.Go(func() (err error) {
+		products, err = fetchProductsPage(gctx, f)
+		return err
+	})
+	g.Go(func() (err error) {
+		transactions, err = fetchTransactionsPage(gctx, f)
+		return err
+	})
+	g.Go(func() (err error) {
+		transactionItems, err = fetchTransactionItemsPage(gctx, f)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
 	}
 
-	payload := DBPayload{
+	return &DBPayload{
 		Users:            users,
 		Products:         products,
 		Transactions:     transactions,
 		TransactionItems: transactionItems,
-	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(payload)
+		UsersPage:            DBPageInfo{NextCursor: lastUserID(users, f.Limit), HasMore: len(users) >= f.Limit},
+		ProductsPage:         DBPageInfo{NextCursor: lastProductID(products, f.Limit), HasMore: len(products) >= f.Limit},
+		TransactionsPage:     DBPageInfo{NextCursor: lastTransactionID(transactions, f.Limit), HasMore: len(transactions) >= f.Limit},
+		TransactionItemsPage: DBPageInfo{NextCursor: lastTransactionItemID(transactionItems, f.Limit), HasMore: len(transactionItems) >= f.Limit},
+	}, nil
 }
 
-func fetchUsers(ctx context.Context) ([]User, error) {
-	rows, err := db.QueryContext(ctx, `
-		SELECT id, email, full_name, phone, password_hash, status, created_at, updated_at
-		FROM users
-	`)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var users []User
-	for rows.Next() {
-		var user User
-		var phone sql.NullString
-		if err := rows.Scan(
-			&user.ID,
-			&user.Email,
-			&user.FullName,
-			&phone,
-			&user.PasswordHash,
-			&user.Status,
-			&user.CreatedAt,
-			&user.UpdatedAt,
-		); err != nil {
-			return nil, err
-		}
-		if phone.Valid {
-			val := phone.String
-			user.Phone = &val
-		}
-		users = append(users, user)
+// lastUserID returns the last row's id when the page is full (a sign there
+// may be more rows behind it), or 0 otherwise — matching next_cursor's
+// omitempty semantics in DBPageInfo.
+func lastUserID(rows []User, limit int) uint64 {
+	if len(rows) < limit {
+		return 0
 	}
-	return users, rows.Err()
+	return rows[len(rows)-1].ID
 }
 
-func fetchProducts(ctx context.Context) ([]Product, error) {
-	rows, err := db.QueryContext(ctx, `
-		SELECT id, sku, name, description, price_cents, stock, is_active, created_at, updated_at
-		FROM products
-	`)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var products []Product
-	for rows.Next() {
-		var prod Product
-		var description sql.NullString
-		if err := rows.Scan(
-			&prod.ID,
-			&prod.SKU,
-			&prod.Name,
-			&description,
-			&prod.PriceCents,
-			&prod.Stock,
-			&prod.IsActive,
-			&prod.CreatedAt,
-			&prod.UpdatedAt,
-		); err != nil {
-			return nil, err
-		}
-		if description.Valid {
-			val := description.String
-			prod.Description = &val
-		}
-		products = append(products, prod)
+func lastProductID(rows []Product, limit int) uint64 {
+	if len(rows) < limit {
+		return 0
 	}
-	return products, rows.Err()
+	return rows[len(rows)-1].ID
 }
 
-func fetchTransactions(ctx context.Context) ([]Transaction, error) {
-	rows, err := db.QueryContext(ctx, `
-		SELECT id, user_id, order_no, status, currency, total_cents, item_count, payment_method, created_at, updated_at
-		FROM transactions
-	`)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var txns []Transaction
-	for rows.Next() {
-		var txn Transaction
-		if err := rows.Scan(
-			&txn.ID,
-			&txn.UserID,
-			&txn.OrderNo,
-			&txn.Status,
-			&txn.Currency,
-			&txn.TotalCents,
-			&txn.ItemCount,
-			&txn.PaymentMethod,
-			&txn.CreatedAt,
-			&txn.UpdatedAt,
-		); err != nil {
-			return nil, err
-		}
-		txns = append(txns, txn)
+func lastTransactionID(rows []Transaction, limit int) uint64 {
+	if len(rows) < limit {
+		return 0
 	}
-	return txns, rows.Err()
+	return rows[len(rows)-1].ID
 }
 
-func fetchTransactionItems(ctx context.Context) ([]TransactionItem, error) {
-	rows, err := db.QueryContext(ctx, `
-		SELECT id, transaction_id, product_id, qty, unit_price_cents, line_total_cents, created_at
-		FROM transaction_items
-	`)
-	if err != nil {
-		return nil, err
+func lastTransactionItemID(rows []TransactionItem, limit int) uint64 {
+	if len(rows) < limit {
+		return 0
 	}
-	defer rows.Close()
-
-	var items []TransactionItem
-	for rows.Next() {
-		var item TransactionItem
-		if err := rows.Scan(
-			&item.ID,
-			&item.TransactionID,
-			&item.ProductID,
-			&item.Qty,
-			&item.UnitPriceCents,
-			&item.LineTotalCents,
-			&item.CreatedAt,
-		); err != nil {
-			return nil, err
-		}
-		items = append(items, item)
-	}
-	return items, rows.Err()
+	return rows[len(rows)-1].ID
 }
 
 func main() {
 	loadEnv()
+	initDataCache()
+	initComputeSemaphore()
+	initRateLimiters()
+
+	rateLimiterSweepCtx, stopRateLimiterSweep := context.WithCancel(context.Background())
+	defer stopRateLimiterSweep()
+	go globalRateLimiters.sweepLoop(rateLimiterSweepCtx, time.Minute, getEnvDuration("RATE_LIMIT_IDLE_TTL", 10*time.Minute))
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/api/v1/auth/load-test", loadTestHandler)
-	mux.HandleFunc("/health", healthHandler)
-	mux.HandleFunc("/metrics", metricsHandler)
-	mux.HandleFunc("/api/v1/data/all", dbDataHandler)
+	mux.HandleFunc("/api/v1/auth/load-test", instrumentHandler("load_test", recoverMiddleware(loadTestHandler)))
+	mux.HandleFunc("/health", instrumentHandler("health", recoverMiddleware(healthHandler)))
+	mux.Handle("/metrics", instrumentHandler("metrics", recoverMiddleware(promhttp.Handler().ServeHTTP)))
+	mux.HandleFunc("/api/v1/data/all", instrumentHandler("data_all", recoverMiddleware(dbDataHandler)))
+
+	var handler http.Handler = mux
+	handler = rateLimitMiddleware(handler)
+	handler = loggingMiddleware(handler)
+	handler = requestIDMiddleware(handler)
 
 	addr := ":31143"
-	log.Printf("Go load-test listening on %s", addr)
 
 	dsn := os.Getenv("DB_DSN")
 	if dsn != "" {
@@ -374,22 +314,57 @@ func main() {
 		}
 		defer db.Close()
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		if err := db.PingContext(ctx); err != nil {
+		db.SetMaxOpenConns(getEnvInt("DB_MAX_OPEN_CONNS", 25))
+		db.SetMaxIdleConns(getEnvInt("DB_MAX_IDLE_CONNS", 25))
+		db.SetConnMaxLifetime(getEnvDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute))
+		db.SetConnMaxIdleTime(getEnvDuration("DB_CONN_MAX_IDLE_TIME", time.Minute))
+
+		pingCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err = db.PingContext(pingCtx)
+		cancel()
+		if err != nil {
 			log.Fatalf("database ping failed: %v", err)
 		}
+
+		statsCtx, stopStats := context.WithCancel(context.Background())
+		defer stopStats()
+		go collectDBStats(statsCtx, 5*time.Second)
 	} else {
 		log.Println("DB_DSN not set; /api/v1/data/all will return 503")
 	}
 
 	server := &http.Server{
 		Addr:         addr,
-		Handler:      mux,
+		Handler:      handler,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
 
-	log.Fatal(server.ListenAndServe())
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Go load-test listening on %s", addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatalf("server error: %v", err)
+		}
+	case <-ctx.Done():
+		stop()
+		log.Println("shutdown signal received, draining in-flight requests")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), getEnvDuration("SHUTDOWN_TIMEOUT", 15*time.Second))
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("graceful shutdown error: %v", err)
+		}
+	}
+
+	log.Println("server stopped")
 }
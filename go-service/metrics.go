@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Total HTTP requests processed, labeled by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency in seconds, labeled by route, method and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	computeDurationSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "compute_duration_seconds",
+		Help:    "Time spent in the load-test compute() CPU loop, per request.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	dbOpenConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "db_pool_open_connections",
+		Help: "Number of established connections to the database, both in use and idle.",
+	})
+	dbInUseConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "db_pool_in_use_connections",
+		Help: "Number of connections currently in use.",
+	})
+	dbIdleConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "db_pool_idle_connections",
+		Help: "Number of idle connections in the pool.",
+	})
+	dbWaitCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "db_pool_wait_count_total",
+		Help: "Total number of connections waited for because the pool was exhausted.",
+	})
+	dbWaitDuration = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "db_pool_wait_duration_seconds_total",
+		Help: "Total time blocked waiting for a new connection.",
+	})
+)
+
+// statusRecorder wraps http.ResponseWriter so instrumentHandler can observe
+// the status code a handler actually writes.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// instrumentHandler wraps h so every call increments http_requests_total and
+// observes http_request_duration_seconds, labeled by route (a stable handler
+// name, not the raw path), method and response status.
+func instrumentHandler(route string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		h(rec, r)
+		status := strconv.Itoa(rec.status)
+		httpRequestsTotal.WithLabelValues(route, r.Method, status).Inc()
+		httpRequestDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// collectDBStats polls db.Stats() until ctx is cancelled and republishes it
+// to the pool gauges/counters above. WaitCount and WaitDuration are
+// cumulative counters in database/sql, so we track the previous reading and
+// add only the delta.
+func collectDBStats(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastWaitCount int64
+	var lastWaitDuration time.Duration
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if db == nil {
+				continue
+			}
+			stats := db.Stats()
+			dbOpenConnections.Set(float64(stats.OpenConnections))
+			dbInUseConnections.Set(float64(stats.InUse))
+			dbIdleConnections.Set(float64(stats.Idle))
+
+			if delta := stats.WaitCount - lastWaitCount; delta > 0 {
+				dbWaitCount.Add(float64(delta))
+			}
+			lastWaitCount = stats.WaitCount
+
+			if delta := stats.WaitDuration - lastWaitDuration; delta > 0 {
+				dbWaitDuration.Add(delta.Seconds())
+			}
+			lastWaitDuration = stats.WaitDuration
+		}
+	}
+}
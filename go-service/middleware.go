@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/time/rate"
+)
+
+type ctxKey string
+
+const requestIDContextKey ctxKey = "request_id"
+
+// requestIDMiddleware assigns each request a stable ID, reusing an inbound
+// X-Request-ID header if the client already set one, stores it on the
+// request context for downstream logging, and echoes it back in the
+// response so callers can correlate logs across hops.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" {
+			id = uuid.NewString()
+		}
+		w.Header().Set("X-Request-ID", id)
+		ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+// accessLogRecorder captures the status code and byte count a handler
+// writes, since net/http exposes neither directly.
+type accessLogRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *accessLogRecorder) WriteHeader(code int) {
+	rec.status = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *accessLogRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// loggingMiddleware emits one structured JSON access log line per request
+// via log/slog, once the handler has finished.
+func loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &accessLogRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+		slog.Info("http_request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"bytes", rec.bytes,
+			"request_id", requestIDFromContext(r.Context()),
+		)
+	})
+}
+
+// recoverMiddleware converts a panic inside h into a 500 response instead
+// of crashing the whole process, so one bad request can't take down
+// in-flight load-test runs. It wraps individual handlers (rather than the
+// whole mux) so instrumentHandler still observes the resulting status.
+func recoverMiddleware(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				log.Printf("panic recovered for %s %s [request_id=%s]: %v", r.Method, r.URL.Path, requestIDFromContext(r.Context()), rec)
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+			}
+		}()
+		h(w, r)
+	}
+}
+
+// ipRateLimiters holds one token-bucket limiter per client IP so a single
+// noisy client can't starve the rest. Entries are evicted by sweep() once
+// unused for a while, so the map doesn't grow without bound as distinct
+// client IPs churn through.
+type ipRateLimiters struct {
+	rps   rate.Limit
+	burst int
+
+	mu       sync.Mutex
+	limiters map[string]*ipRateLimiterEntry
+}
+
+type ipRateLimiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newIPRateLimiters(rps float64, burst int) *ipRateLimiters {
+	return &ipRateLimiters{
+		rps:      rate.Limit(rps),
+		burst:    burst,
+		limiters: make(map[string]*ipRateLimiterEntry),
+	}
+}
+
+func (rl *ipRateLimiters) get(ip string) *rate.Limiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	entry, ok := rl.limiters[ip]
+	if !ok {
+		entry = &ipRateLimiterEntry{limiter: rate.NewLimiter(rl.rps, rl.burst)}
+		rl.limiters[ip] = entry
+	}
+	entry.lastSeen = time.Now()
+	return entry.limiter
+}
+
+// sweep drops limiters that haven't been used in the last maxIdle, so a
+// client that hits the service once doesn't hold a limiter in memory
+// forever.
+func (rl *ipRateLimiters) sweep(maxIdle time.Duration) {
+	cutoff := time.Now().Add(-maxIdle)
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	for ip, entry := range rl.limiters {
+		if entry.lastSeen.Before(cutoff) {
+			delete(rl.limiters, ip)
+		}
+	}
+}
+
+// sweepLoop runs sweep on a fixed interval until ctx is cancelled.
+func (rl *ipRateLimiters) sweepLoop(ctx context.Context, interval, maxIdle time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rl.sweep(maxIdle)
+		}
+	}
+}
+
+var globalRateLimiters *ipRateLimiters
+
+// initRateLimiters builds globalRateLimiters from RATE_LIMIT_RPS and
+// RATE_LIMIT_BURST. It must run after loadEnv() (called from main()): a
+// package-level var initializer runs before main() executes, i.e. before
+// loadEnv() has had a chance to load .env, which would silently ignore
+// values set there.
+func initRateLimiters() {
+	globalRateLimiters = newIPRateLimiters(
+		getEnvFloat("RATE_LIMIT_RPS", 50),
+		getEnvInt("RATE_LIMIT_BURST", 100),
+	)
+}
+
+// rateLimitMiddleware enforces a per-client-IP token-bucket rate limit,
+// returning 429 with Retry-After so load-test clients back off correctly
+// instead of hammering an already-saturated service.
+func rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limiter := globalRateLimiters.get(clientIP(r))
+		if !limiter.Allow() {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}